@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Content types recognized by the built-in codecs. A Publish configuration's
+// ContentType selects which codec AMQPMessage encodes with; on the
+// consume side, Decoder reads amqp.Delivery.ContentType to pick its match.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgpack  = "application/x-msgpack"
+	ContentTypeGob      = "application/x-gob"
+)
+
+// Codec encodes and decodes message bodies for a given wire format
+type Codec interface {
+	// Encode marshals v, returning the body and the content type that should
+	// be set on the resulting amqp.Publishing
+	Encode(v interface{}) ([]byte, string, error)
+	// Decode unmarshals data, whose wire format is identified by contentType,
+	// into v
+	Decode(data []byte, contentType string, v interface{}) error
+}
+
+// JSONCodec encodes and decodes using encoding/json
+type JSONCodec struct{}
+
+// Encode marshals v as JSON
+func (JSONCodec) Encode(v interface{}) ([]byte, string, error) {
+	body, err := json.Marshal(v)
+	return body, ContentTypeJSON, err
+}
+
+// Decode unmarshals a JSON body into v
+func (JSONCodec) Decode(data []byte, contentType string, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtobufCodec encodes and decodes using protocol buffers. v must implement
+// proto.Message.
+type ProtobufCodec struct{}
+
+// Encode marshals v as a protobuf message
+func (ProtobufCodec) Encode(v interface{}) ([]byte, string, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, "", errors.New("value does not implement proto.Message")
+	}
+	body, err := proto.Marshal(msg)
+	return body, ContentTypeProtobuf, err
+}
+
+// Decode unmarshals a protobuf body into v
+func (ProtobufCodec) Decode(data []byte, contentType string, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("value does not implement proto.Message")
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// MsgpackCodec encodes and decodes using MessagePack
+type MsgpackCodec struct{}
+
+// Encode marshals v as MessagePack
+func (MsgpackCodec) Encode(v interface{}) ([]byte, string, error) {
+	body, err := msgpack.Marshal(v)
+	return body, ContentTypeMsgpack, err
+}
+
+// Decode unmarshals a MessagePack body into v
+func (MsgpackCodec) Decode(data []byte, contentType string, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// GobCodec encodes and decodes using encoding/gob
+type GobCodec struct{}
+
+// Encode marshals v using gob
+func (GobCodec) Encode(v interface{}) ([]byte, string, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), ContentTypeGob, err
+}
+
+// Decode unmarshals a gob body into v
+func (GobCodec) Decode(data []byte, contentType string, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// CodecForContentType returns the built-in Codec registered for contentType,
+// falling back to JSONCodec for an empty or unrecognized content type
+func CodecForContentType(contentType string) Codec {
+	switch contentType {
+	case ContentTypeProtobuf:
+		return ProtobufCodec{}
+	case ContentTypeMsgpack:
+		return MsgpackCodec{}
+	case ContentTypeGob:
+		return GobCodec{}
+	default:
+		return JSONCodec{}
+	}
+}
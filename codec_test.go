@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+)
+
+type codecTestPayload struct {
+	Name  string
+	Count int
+}
+
+func TestJSONCodec_RoundTrip(t *testing.T) {
+	in := codecTestPayload{Name: "widget", Count: 3}
+	body, contentType, err := (JSONCodec{}).Encode(in)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	if contentType != ContentTypeJSON {
+		t.Fatalf("expected content type %q, got %q", ContentTypeJSON, contentType)
+	}
+
+	var out codecTestPayload
+	if err := (JSONCodec{}).Decode(body, contentType, &out); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestMsgpackCodec_RoundTrip(t *testing.T) {
+	in := codecTestPayload{Name: "widget", Count: 3}
+	body, contentType, err := (MsgpackCodec{}).Encode(in)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	if contentType != ContentTypeMsgpack {
+		t.Fatalf("expected content type %q, got %q", ContentTypeMsgpack, contentType)
+	}
+
+	var out codecTestPayload
+	if err := (MsgpackCodec{}).Decode(body, contentType, &out); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestGobCodec_RoundTrip(t *testing.T) {
+	in := codecTestPayload{Name: "widget", Count: 3}
+	body, contentType, err := (GobCodec{}).Encode(in)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	if contentType != ContentTypeGob {
+		t.Fatalf("expected content type %q, got %q", ContentTypeGob, contentType)
+	}
+
+	var out codecTestPayload
+	if err := (GobCodec{}).Decode(body, contentType, &out); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestProtobufCodec_RejectsNonProtoValue(t *testing.T) {
+	if _, _, err := (ProtobufCodec{}).Encode(codecTestPayload{}); err == nil {
+		t.Fatal("expected Encode to reject a value that does not implement proto.Message")
+	}
+	if err := (ProtobufCodec{}).Decode(nil, ContentTypeProtobuf, &codecTestPayload{}); err == nil {
+		t.Fatal("expected Decode to reject a value that does not implement proto.Message")
+	}
+}
+
+func TestCodecForContentType(t *testing.T) {
+	cases := []struct {
+		contentType string
+		want        Codec
+	}{
+		{ContentTypeProtobuf, ProtobufCodec{}},
+		{ContentTypeMsgpack, MsgpackCodec{}},
+		{ContentTypeGob, GobCodec{}},
+		{ContentTypeJSON, JSONCodec{}},
+		{"", JSONCodec{}},
+		{"application/unknown", JSONCodec{}},
+	}
+	for _, c := range cases {
+		if got := CodecForContentType(c.contentType); reflect.TypeOf(got) != reflect.TypeOf(c.want) {
+			t.Errorf("CodecForContentType(%q) = %T, want %T", c.contentType, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/whiteblock/amqp/config"
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// ReconnectMinBackoff is the initial delay between reconnect attempts
+const ReconnectMinBackoff = time.Second
+
+// ReconnectMaxBackoff is the maximum delay between reconnect attempts
+const ReconnectMaxBackoff = 30 * time.Second
+
+// Handler processes a single delivery from a consumer
+type Handler func(amqp.Delivery)
+
+// amqpConnection is the subset of *amqp.Connection's behavior ConnectionManager
+// depends on. Abstracting it behind an interface (rather than depending on
+// *amqp.Connection directly) lets tests substitute a fake connection to
+// exercise reconnect and re-subscribe behavior without dialing a live broker.
+type amqpConnection interface {
+	Channel() (externals.AMQPChannel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// realConnection adapts *amqp.Connection to amqpConnection. NotifyClose and
+// Close are promoted as-is; only Channel needs adapting, since *amqp.Channel
+// satisfies externals.AMQPChannel structurally but isn't that type itself.
+type realConnection struct {
+	*amqp.Connection
+}
+
+func (r realConnection) Channel() (externals.AMQPChannel, error) {
+	return r.Connection.Channel()
+}
+
+// dialAMQP opens a real AMQP connection via OpenAMQPConnection, adapted to
+// amqpConnection. This is the dial func NewConnectionManager uses; tests use
+// newConnectionManager directly with a fake one instead.
+func dialAMQP(conf config.Endpoint) (amqpConnection, error) {
+	conn, err := OpenAMQPConnection(conf)
+	if err != nil {
+		return nil, err
+	}
+	return realConnection{conn}, nil
+}
+
+// ConnectionManager wraps a single amqp connection, transparently
+// reconnecting with exponential backoff when the connection is lost, and
+// hands out a pool of reusable channels backed by that connection
+type ConnectionManager struct {
+	log  logrus.Ext1FieldLogger
+	conf config.Endpoint
+	dial func(config.Endpoint) (amqpConnection, error)
+
+	mu       sync.Mutex
+	conn     amqpConnection
+	pool     []externals.AMQPChannel
+	consumes []registeredConsumer
+
+	observers []chan<- bool
+}
+
+type registeredConsumer struct {
+	svc     AMQPService
+	handler Handler
+}
+
+// NewConnectionManager dials conf and returns a ConnectionManager that
+// supervises the connection, reconnecting on failure
+func NewConnectionManager(log logrus.Ext1FieldLogger, conf config.Endpoint) (*ConnectionManager, error) {
+	return newConnectionManager(log, conf, dialAMQP)
+}
+
+// newConnectionManager is NewConnectionManager with an injectable dial func,
+// so tests can exercise supervise()'s reconnect and re-subscribe logic
+// against a fake amqpConnection instead of a live broker
+func newConnectionManager(log logrus.Ext1FieldLogger, conf config.Endpoint, dial func(config.Endpoint) (amqpConnection, error)) (*ConnectionManager, error) {
+	conn, err := dial(conf)
+	if err != nil {
+		return nil, err
+	}
+	cm := &ConnectionManager{log: log, conf: conf, dial: dial, conn: conn}
+	cm.supervise()
+	return cm, nil
+}
+
+// Observe registers ch to receive true whenever the connection is
+// re-established, and false right after it is lost, so application code can
+// pause/resume work around a reconnect. ch must be buffered to hold at least
+// the two most recent states: notify never blocks on a full or unready
+// observer, so an unbuffered or saturated channel will silently miss states
+// instead of stalling the manager.
+func (cm *ConnectionManager) Observe(ch chan<- bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.observers = append(cm.observers, ch)
+}
+
+// Channel returns a channel from the pool, opening a new one on the current
+// connection if none are free. Since the pool is drained on every reconnect
+// (see supervise), a channel handed out by Channel is always backed by
+// whichever connection is current at the time of the call.
+func (cm *ConnectionManager) Channel() (externals.AMQPChannel, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if len(cm.pool) > 0 {
+		ch := cm.pool[len(cm.pool)-1]
+		cm.pool = cm.pool[:len(cm.pool)-1]
+		return ch, nil
+	}
+	return cm.conn.Channel()
+}
+
+// Release returns a channel to the pool for reuse
+func (cm *ConnectionManager) Release(ch externals.AMQPChannel) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.pool = append(cm.pool, ch)
+}
+
+// Consume registers handler to process deliveries for svc's queue,
+// transparently re-subscribing svc after a reconnect
+func (cm *ConnectionManager) Consume(svc AMQPService, handler Handler) error {
+	cm.mu.Lock()
+	cm.consumes = append(cm.consumes, registeredConsumer{svc: svc, handler: handler})
+	cm.mu.Unlock()
+
+	return cm.startConsumer(svc, handler)
+}
+
+// startConsumer re-declares svc's queue/exchange/binding via the existing
+// AutoSetup logic, then consumes on a channel from cm's own pool rather than
+// svc.Channel(): cm.Channel() always comes from the connection supervise
+// currently holds, so a consumer started this way is re-subscribed onto the
+// new connection after a reconnect instead of an AMQPService-owned channel
+// that may still point at the dead one.
+func (cm *ConnectionManager) startConsumer(svc AMQPService, handler Handler) error {
+	AutoSetup(cm.log, svc)
+
+	cm.mu.Lock()
+	conn := cm.conn
+	cm.mu.Unlock()
+
+	ch, err := cm.Channel()
+	if err != nil {
+		return errors.Wrap(err, "failed to get channel for consumer")
+	}
+
+	deliveries, err := ch.Consume(svc.Config().QueueName, "", false, false, false, false, nil)
+	if err != nil {
+		cm.Release(ch)
+		return errors.Wrap(err, "failed to start consuming")
+	}
+
+	go func() {
+		for d := range deliveries {
+			handler(d)
+		}
+		// a channel whose connection supervise has since replaced is dropped
+		// rather than returned to the pool
+		cm.mu.Lock()
+		if cm.conn == conn {
+			cm.pool = append(cm.pool, ch)
+		}
+		cm.mu.Unlock()
+	}()
+	return nil
+}
+
+// supervise watches the connection's close notifications and reconnects
+// with exponential backoff, re-subscribing every registered consumer and
+// notifying observers of the state change
+func (cm *ConnectionManager) supervise() {
+	closeChan := cm.conn.NotifyClose(make(chan *amqp.Error))
+
+	go func() {
+		err := <-closeChan
+		cm.log.WithFields(logrus.Fields{"err": err}).Warn("amqp connection closed, reconnecting")
+		cm.notify(false)
+
+		backoff := ReconnectMinBackoff
+		for {
+			conn, dialErr := cm.dial(cm.conf)
+			if dialErr == nil {
+				cm.mu.Lock()
+				cm.conn = conn
+				cm.pool = nil
+				consumes := cm.consumes
+				cm.mu.Unlock()
+
+				for _, c := range consumes {
+					if startErr := cm.startConsumer(c.svc, c.handler); startErr != nil {
+						cm.log.WithFields(logrus.Fields{"err": startErr, "queue": c.svc.Config().QueueName}).
+							Error("failed to re-subscribe consumer after reconnect")
+					}
+				}
+
+				cm.notify(true)
+				cm.supervise()
+				return
+			}
+
+			cm.log.WithFields(logrus.Fields{"err": dialErr}).Warn("failed to reconnect, retrying")
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > ReconnectMaxBackoff {
+				backoff = ReconnectMaxBackoff
+			}
+		}
+	}()
+}
+
+// notify broadcasts state to every observer without holding cm.mu for the
+// send: a single slow or unready observer must not be able to stall Channel,
+// Release, Consume, or Observe. Observers that aren't ready to receive miss
+// the notification rather than blocking the broadcast.
+func (cm *ConnectionManager) notify(state bool) {
+	cm.mu.Lock()
+	observers := make([]chan<- bool, len(cm.observers))
+	copy(observers, cm.observers)
+	cm.mu.Unlock()
+
+	for _, obs := range observers {
+		select {
+		case obs <- state:
+		default:
+			cm.log.Warn("observer channel not ready, dropped a connection state notification")
+		}
+	}
+}
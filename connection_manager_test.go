@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/whiteblock/amqp/config"
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// newTestConnectionManager builds a ConnectionManager with no live connection,
+// enough to exercise Observe/notify without dialing a broker.
+func newTestConnectionManager() *ConnectionManager {
+	return &ConnectionManager{log: logrus.New()}
+}
+
+func TestConnectionManager_NotifyBroadcastsToReadyObservers(t *testing.T) {
+	cm := newTestConnectionManager()
+
+	a := make(chan bool, 2)
+	b := make(chan bool, 2)
+	cm.Observe(a)
+	cm.Observe(b)
+
+	cm.notify(false)
+	cm.notify(true)
+
+	for _, ch := range []chan bool{a, b} {
+		if got := <-ch; got != false {
+			t.Fatalf("expected first notification to be false, got %v", got)
+		}
+		if got := <-ch; got != true {
+			t.Fatalf("expected second notification to be true, got %v", got)
+		}
+	}
+}
+
+func TestConnectionManager_NotifyDoesNotBlockOnUnreadyObserver(t *testing.T) {
+	cm := newTestConnectionManager()
+
+	full := make(chan bool) // unbuffered and never read: notify must not block on it
+	cm.Observe(full)
+
+	done := make(chan struct{})
+	go func() {
+		cm.notify(true)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify blocked on an observer that wasn't ready to receive")
+	}
+}
+
+// fakeAMQPConnection is a fake amqpConnection: NotifyClose hands back
+// whatever receiver it's given (so the test can push a close error into it),
+// and Channel returns whatever channel the test configured.
+type fakeAMQPConnection struct {
+	channel   externals.AMQPChannel
+	closeChan chan *amqp.Error
+}
+
+func (f *fakeAMQPConnection) Channel() (externals.AMQPChannel, error) {
+	return f.channel, nil
+}
+
+func (f *fakeAMQPConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	f.closeChan = receiver
+	return receiver
+}
+
+func (f *fakeAMQPConnection) Close() error { return nil }
+
+// fakeConsumeChannel is a fake externals.AMQPChannel whose Consume pings
+// calls on a buffered channel, so a test can wait for a (re-)subscription to
+// actually happen instead of racing on a sleep.
+type fakeConsumeChannel struct {
+	calls int32
+	pings chan struct{}
+}
+
+func newFakeConsumeChannel() *fakeConsumeChannel {
+	return &fakeConsumeChannel{pings: make(chan struct{}, 8)}
+}
+
+func (f *fakeConsumeChannel) Close() error { return nil }
+
+func (f *fakeConsumeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	atomic.AddInt32(&f.calls, 1)
+	f.pings <- struct{}{}
+	deliveries := make(chan amqp.Delivery)
+	close(deliveries)
+	return deliveries, nil
+}
+
+func (f *fakeConsumeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return nil
+}
+func (f *fakeConsumeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+func (f *fakeConsumeChannel) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error {
+	return nil
+}
+func (f *fakeConsumeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return nil
+}
+func (f *fakeConsumeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return nil
+}
+func (f *fakeConsumeChannel) Tx() error         { return nil }
+func (f *fakeConsumeChannel) TxCommit() error   { return nil }
+func (f *fakeConsumeChannel) TxRollback() error { return nil }
+
+// fakeAMQPService is a fake AMQPService: Channel/Config return whatever the
+// test configured, CreateQueue/CreateExchange are no-ops since this test
+// cares about re-subscription, not topology declaration.
+type fakeAMQPService struct {
+	conf config.Config
+	ch   externals.AMQPChannel
+}
+
+func (s *fakeAMQPService) CreateQueue() error                      { return nil }
+func (s *fakeAMQPService) CreateExchange() error                   { return nil }
+func (s *fakeAMQPService) Channel() (externals.AMQPChannel, error) { return s.ch, nil }
+func (s *fakeAMQPService) Config() config.Config                   { return s.conf }
+
+func waitForPing(t *testing.T, pings chan struct{}) {
+	t.Helper()
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Consume to be called")
+	}
+}
+
+func TestConnectionManager_ReconnectResubscribesConsumers(t *testing.T) {
+	ch := newFakeConsumeChannel()
+	first := &fakeAMQPConnection{channel: ch}
+	second := &fakeAMQPConnection{channel: ch}
+
+	var dialCalls int32
+	conns := []*fakeAMQPConnection{first, second}
+	dial := func(conf config.Endpoint) (amqpConnection, error) {
+		i := atomic.AddInt32(&dialCalls, 1) - 1
+		if int(i) >= len(conns) {
+			i = int32(len(conns) - 1)
+		}
+		return conns[i], nil
+	}
+
+	cm, err := newConnectionManager(logrus.New(), config.Endpoint{}, dial)
+	if err != nil {
+		t.Fatalf("newConnectionManager returned error: %v", err)
+	}
+
+	svc := &fakeAMQPService{conf: config.Config{QueueName: "q"}, ch: ch}
+	if err := cm.Consume(svc, func(amqp.Delivery) {}); err != nil {
+		t.Fatalf("Consume returned error: %v", err)
+	}
+	waitForPing(t, ch.pings)
+
+	if got := atomic.LoadInt32(&ch.calls); got != 1 {
+		t.Fatalf("expected 1 Consume call before reconnect, got %d", got)
+	}
+
+	// simulate the broker closing the connection
+	first.closeChan <- amqp.ErrClosed
+	waitForPing(t, ch.pings)
+
+	if got := atomic.LoadInt32(&ch.calls); got != 2 {
+		t.Fatalf("expected the consumer to be re-subscribed after reconnect, got %d Consume calls", got)
+	}
+	if got := atomic.LoadInt32(&dialCalls); got != 2 {
+		t.Fatalf("expected dial to be called once for the initial connection and once to reconnect, got %d", got)
+	}
+}
@@ -0,0 +1,45 @@
+package queue
+
+import (
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// Decoder selects the Codec matching a delivery's ContentType and decodes
+// its body, the symmetric counterpart to encoding with a Codec on publish
+type Decoder struct {
+	codecs map[string]Codec
+}
+
+// NewDecoder creates a Decoder with the built-in JSON, Protobuf, MessagePack,
+// and gob codecs registered, keyed by content type
+func NewDecoder() *Decoder {
+	return &Decoder{
+		codecs: map[string]Codec{
+			ContentTypeJSON:     JSONCodec{},
+			ContentTypeProtobuf: ProtobufCodec{},
+			ContentTypeMsgpack:  MsgpackCodec{},
+			ContentTypeGob:      GobCodec{},
+		},
+	}
+}
+
+// Register adds or overrides the codec used for contentType
+func (d *Decoder) Register(contentType string, codec Codec) {
+	d.codecs[contentType] = codec
+}
+
+// Decode looks up the codec for msg.ContentType and decodes its body into v.
+// A missing or empty ContentType falls back to JSON, matching the behavior
+// of messages produced before ContentType was set.
+func (d *Decoder) Decode(msg amqp.Delivery, v interface{}) error {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+	codec, ok := d.codecs[contentType]
+	if !ok {
+		return errors.Errorf("no codec registered for content type %q", contentType)
+	}
+	return codec.Decode(msg.Body, contentType, v)
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+func TestDecoder_DecodesByContentType(t *testing.T) {
+	d := NewDecoder()
+	in := codecTestPayload{Name: "widget", Count: 3}
+	body, contentType, err := (JSONCodec{}).Encode(in)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	var out codecTestPayload
+	msg := amqp.Delivery{Body: body, ContentType: contentType}
+	if err := d.Decode(msg, &out); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if out != in {
+		t.Fatalf("decoded %+v, want %+v", out, in)
+	}
+}
+
+func TestDecoder_EmptyContentTypeFallsBackToJSON(t *testing.T) {
+	d := NewDecoder()
+	in := codecTestPayload{Name: "widget", Count: 3}
+	body, _, err := (JSONCodec{}).Encode(in)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	var out codecTestPayload
+	msg := amqp.Delivery{Body: body}
+	if err := d.Decode(msg, &out); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if out != in {
+		t.Fatalf("decoded %+v, want %+v", out, in)
+	}
+}
+
+func TestDecoder_UnregisteredContentTypeErrors(t *testing.T) {
+	d := NewDecoder()
+	msg := amqp.Delivery{Body: []byte("irrelevant"), ContentType: "application/unknown"}
+	if err := d.Decode(msg, &codecTestPayload{}); err == nil {
+		t.Fatal("expected an error for an unregistered content type")
+	}
+}
+
+func TestDecoder_Register(t *testing.T) {
+	d := NewDecoder()
+	d.Register(ContentTypeJSON, MsgpackCodec{})
+
+	in := codecTestPayload{Name: "widget", Count: 3}
+	body, _, err := (MsgpackCodec{}).Encode(in)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	var out codecTestPayload
+	msg := amqp.Delivery{Body: body, ContentType: ContentTypeJSON}
+	if err := d.Decode(msg, &out); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if out != in {
+		t.Fatalf("decoded %+v, want %+v", out, in)
+	}
+}
@@ -17,3 +17,11 @@ type AMQPChannel interface {
 	TxCommit() error
 	TxRollback() error
 }
+
+// ConfirmingAMQPChannel is an AMQPChannel that additionally supports RabbitMQ
+// publisher confirms
+type ConfirmingAMQPChannel interface {
+	AMQPChannel
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+}
@@ -0,0 +1,119 @@
+package observability
+
+import (
+	"time"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/streadway/amqp"
+)
+
+type instrumentedChannel struct {
+	externals.AMQPChannel
+	metrics *Metrics
+}
+
+// instrumentedConfirmingChannel is an instrumentedChannel that also
+// preserves externals.ConfirmingAMQPChannel, so wrapping a confirming
+// channel with metrics doesn't stop it from being usable as one, e.g. with
+// NewReliablePublisher.
+type instrumentedConfirmingChannel struct {
+	instrumentedChannel
+	confirmer externals.ConfirmingAMQPChannel
+}
+
+// NewInstrumentedChannel decorates ch with Prometheus counters and
+// histograms recorded on metrics. Callers can opt in without changing any
+// other call sites since the result still satisfies externals.AMQPChannel
+// (and externals.ConfirmingAMQPChannel, if ch did). metrics should be
+// created once with NewMetrics and shared across every channel wrapped for
+// the same service, since a prometheus.Registerer panics on a duplicate
+// collector registration.
+func NewInstrumentedChannel(ch externals.AMQPChannel, metrics *Metrics) externals.AMQPChannel {
+	base := instrumentedChannel{AMQPChannel: ch, metrics: metrics}
+	if confirmer, ok := ch.(externals.ConfirmingAMQPChannel); ok {
+		return &instrumentedConfirmingChannel{instrumentedChannel: base, confirmer: confirmer}
+	}
+	return &base
+}
+
+// Confirm delegates to the wrapped confirming channel
+func (icc *instrumentedConfirmingChannel) Confirm(noWait bool) error {
+	return icc.confirmer.Confirm(noWait)
+}
+
+// NotifyPublish delegates to the wrapped confirming channel
+func (icc *instrumentedConfirmingChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	return icc.confirmer.NotifyPublish(confirm)
+}
+
+// Publish times the wrapped Publish call and records it as published
+func (ic *instrumentedChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	start := time.Now()
+	err := ic.AMQPChannel.Publish(exchange, key, mandatory, immediate, msg)
+	ic.metrics.PublishLatency.WithLabelValues(exchange, key).Observe(time.Since(start).Seconds())
+	if err == nil {
+		ic.metrics.Published.WithLabelValues(exchange, key).Inc()
+	}
+	return err
+}
+
+// Consume wraps each delivery's Acknowledger so acks, nacks, and handler
+// latency are recorded, and counts each delivery as consumed
+func (ic *instrumentedChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	deliveries, err := ic.AMQPChannel.Consume(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan amqp.Delivery)
+	go func() {
+		defer close(out)
+		for d := range deliveries {
+			ic.metrics.Consumed.WithLabelValues(queue).Inc()
+			ic.metrics.InFlight.WithLabelValues(queue).Inc()
+
+			if d.Acknowledger != nil {
+				d.Acknowledger = &instrumentedAcknowledger{
+					Acknowledger: d.Acknowledger,
+					metrics:      ic.metrics,
+					queue:        queue,
+					start:        time.Now(),
+				}
+			}
+			out <- d
+		}
+	}()
+	return out, nil
+}
+
+type instrumentedAcknowledger struct {
+	amqp.Acknowledger
+	metrics *Metrics
+	queue   string
+	start   time.Time
+}
+
+func (a *instrumentedAcknowledger) Ack(tag uint64, multiple bool) error {
+	err := a.Acknowledger.Ack(tag, multiple)
+	a.metrics.Acked.WithLabelValues(a.queue).Inc()
+	a.metrics.InFlight.WithLabelValues(a.queue).Dec()
+	a.metrics.HandlerLatency.WithLabelValues(a.queue).Observe(time.Since(a.start).Seconds())
+	return err
+}
+
+func (a *instrumentedAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	err := a.Acknowledger.Nack(tag, multiple, requeue)
+	a.metrics.Nacked.WithLabelValues(a.queue).Inc()
+	a.metrics.InFlight.WithLabelValues(a.queue).Dec()
+	a.metrics.HandlerLatency.WithLabelValues(a.queue).Observe(time.Since(a.start).Seconds())
+	return err
+}
+
+func (a *instrumentedAcknowledger) Reject(tag uint64, requeue bool) error {
+	err := a.Acknowledger.Reject(tag, requeue)
+	a.metrics.Nacked.WithLabelValues(a.queue).Inc()
+	a.metrics.InFlight.WithLabelValues(a.queue).Dec()
+	a.metrics.HandlerLatency.WithLabelValues(a.queue).Observe(time.Since(a.start).Seconds())
+	return err
+}
@@ -0,0 +1,155 @@
+package observability
+
+import (
+	"testing"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streadway/amqp"
+)
+
+// fakeChannel implements externals.AMQPChannel, recording Publish calls and
+// serving Consume from a channel the test controls directly.
+type fakeChannel struct {
+	publishCalls int
+	publishErr   error
+	deliveries   chan amqp.Delivery
+}
+
+func (f *fakeChannel) Close() error { return nil }
+
+func (f *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	return f.deliveries, nil
+}
+
+func (f *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	f.publishCalls++
+	return f.publishErr
+}
+
+func (f *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+func (f *fakeChannel) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error {
+	return nil
+}
+func (f *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return nil
+}
+func (f *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return nil
+}
+func (f *fakeChannel) Tx() error         { return nil }
+func (f *fakeChannel) TxCommit() error   { return nil }
+func (f *fakeChannel) TxRollback() error { return nil }
+
+// fakeConfirmingChannel additionally supports publisher confirms, to verify
+// NewInstrumentedChannel preserves that capability through the wrapper.
+type fakeConfirmingChannel struct {
+	fakeChannel
+}
+
+func (f *fakeConfirmingChannel) Confirm(noWait bool) error { return nil }
+func (f *fakeConfirmingChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	return confirm
+}
+
+type fakeAcknowledger struct {
+	acked  bool
+	nacked bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error  { a.acked = true; return nil }
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	return nil
+}
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error { a.nacked = true; return nil }
+
+func TestNewInstrumentedChannel_PreservesConfirmingCapability(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+	wrapped := NewInstrumentedChannel(&fakeConfirmingChannel{}, metrics)
+
+	if _, ok := wrapped.(externals.ConfirmingAMQPChannel); !ok {
+		t.Fatal("expected wrapping a confirming channel to preserve externals.ConfirmingAMQPChannel")
+	}
+}
+
+func TestNewInstrumentedChannel_PlainChannelStaysPlain(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+	wrapped := NewInstrumentedChannel(&fakeChannel{}, metrics)
+
+	if _, ok := wrapped.(externals.ConfirmingAMQPChannel); ok {
+		t.Fatal("expected wrapping a plain channel to not produce a ConfirmingAMQPChannel")
+	}
+}
+
+func TestInstrumentedChannel_PublishDelegatesAndRecordsMetrics(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+	base := &fakeChannel{}
+	wrapped := NewInstrumentedChannel(base, metrics)
+
+	if err := wrapped.Publish("ex", "key", false, false, amqp.Publishing{}); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	if base.publishCalls != 1 {
+		t.Fatalf("expected Publish to delegate to the wrapped channel once, got %d calls", base.publishCalls)
+	}
+	if got := testutil.ToFloat64(metrics.Published.WithLabelValues("ex", "key")); got != 1 {
+		t.Fatalf("expected Published counter to be 1, got %v", got)
+	}
+}
+
+func TestInstrumentedChannel_PublishErrorDoesNotCountAsPublished(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+	base := &fakeChannel{publishErr: errPublishFailed}
+	wrapped := NewInstrumentedChannel(base, metrics)
+
+	if err := wrapped.Publish("ex", "key", false, false, amqp.Publishing{}); err == nil {
+		t.Fatal("expected Publish to return the wrapped channel's error")
+	}
+	if got := testutil.ToFloat64(metrics.Published.WithLabelValues("ex", "key")); got != 0 {
+		t.Fatalf("expected Published counter to stay 0 on a failed publish, got %v", got)
+	}
+}
+
+func TestInstrumentedChannel_ConsumeWrapsAcknowledgerAndRecordsMetrics(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+	base := &fakeChannel{deliveries: make(chan amqp.Delivery, 1)}
+	wrapped := NewInstrumentedChannel(base, metrics)
+
+	ack := &fakeAcknowledger{}
+	base.deliveries <- amqp.Delivery{Acknowledger: ack}
+	close(base.deliveries)
+
+	out, err := wrapped.Consume("q", "", false, false, false, false, nil)
+	if err != nil {
+		t.Fatalf("Consume returned error: %v", err)
+	}
+
+	d, ok := <-out
+	if !ok {
+		t.Fatal("expected a delivery from Consume")
+	}
+	if got := testutil.ToFloat64(metrics.Consumed.WithLabelValues("q")); got != 1 {
+		t.Fatalf("expected Consumed counter to be 1, got %v", got)
+	}
+
+	if err := d.Acknowledger.Ack(0, false); err != nil {
+		t.Fatalf("Ack returned error: %v", err)
+	}
+	if !ack.acked {
+		t.Fatal("expected the wrapped acknowledger's Ack to delegate to the original")
+	}
+	if got := testutil.ToFloat64(metrics.Acked.WithLabelValues("q")); got != 1 {
+		t.Fatalf("expected Acked counter to be 1, got %v", got)
+	}
+}
+
+var errPublishFailed = publishError("publish failed")
+
+type publishError string
+
+func (e publishError) Error() string { return string(e) }
@@ -0,0 +1,22 @@
+package observability
+
+import (
+	"github.com/whiteblock/amqp"
+)
+
+// ObserveReconnects subscribes to cm's connection state notifications and
+// increments metrics.Reconnects every time the connection is re-established.
+// The channel is buffered to hold the two most recent states, as
+// ConnectionManager.Observe requires.
+func ObserveReconnects(cm *queue.ConnectionManager, metrics *Metrics) {
+	state := make(chan bool, 2)
+	cm.Observe(state)
+
+	go func() {
+		for reconnected := range state {
+			if reconnected {
+				metrics.Reconnects.Inc()
+			}
+		}
+	}()
+}
@@ -0,0 +1,29 @@
+package observability
+
+import (
+	"github.com/whiteblock/amqp"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// DeliveryFields extracts the correlation id, routing key, exchange, and
+// retry count off a delivery for structured logging
+func DeliveryFields(d amqp.Delivery) logrus.Fields {
+	fields := logrus.Fields{
+		"correlation_id": d.CorrelationId,
+		"routing_key":    d.RoutingKey,
+		"exchange":       d.Exchange,
+	}
+	if d.Headers != nil {
+		if count, ok := d.Headers[queue.RetryCountHeader].(int64); ok {
+			fields["retry_count"] = count
+		}
+	}
+	return fields
+}
+
+// LoggerForDelivery returns log enriched with DeliveryFields for d
+func LoggerForDelivery(log logrus.Ext1FieldLogger, d amqp.Delivery) logrus.Ext1FieldLogger {
+	return log.WithFields(DeliveryFields(d))
+}
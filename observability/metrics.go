@@ -0,0 +1,87 @@
+// Package observability decorates an AMQPService/externals.AMQPChannel with
+// Prometheus metrics and structured logging, without requiring call sites to
+// change.
+package observability
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors shared by an instrumented channel
+// and retry strategy. Use NewMetrics to create and register one.
+type Metrics struct {
+	Published         *prometheus.CounterVec
+	Consumed          *prometheus.CounterVec
+	Acked             *prometheus.CounterVec
+	Nacked            *prometheus.CounterVec
+	Retried           *prometheus.CounterVec
+	KickbackExhausted *prometheus.CounterVec
+	Reconnects        prometheus.Counter
+
+	InFlight *prometheus.GaugeVec
+
+	PublishLatency *prometheus.HistogramVec
+	HandlerLatency *prometheus.HistogramVec
+}
+
+// NewMetrics creates the collectors and registers them with reg
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "messages_published_total",
+			Help:      "Number of messages published.",
+		}, []string{"exchange", "routing_key"}),
+		Consumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "messages_consumed_total",
+			Help:      "Number of messages delivered to a consumer.",
+		}, []string{"queue"}),
+		Acked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "messages_acked_total",
+			Help:      "Number of deliveries acked.",
+		}, []string{"queue"}),
+		Nacked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "messages_nacked_total",
+			Help:      "Number of deliveries nacked or rejected.",
+		}, []string{"queue"}),
+		Retried: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "messages_retried_total",
+			Help:      "Number of deliveries handed to a RetryStrategy for another attempt.",
+		}, []string{"queue"}),
+		KickbackExhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "messages_kickback_exhausted_total",
+			Help:      "Number of deliveries a RetryStrategy gave up on after exhausting retries.",
+		}, []string{"queue"}),
+		Reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "amqp",
+			Name:      "reconnects_total",
+			Help:      "Number of times the connection was re-established after being lost.",
+		}),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "amqp",
+			Name:      "deliveries_in_flight",
+			Help:      "Number of deliveries received but not yet acked, nacked, or rejected.",
+		}, []string{"queue"}),
+		PublishLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "amqp",
+			Name:      "publish_latency_seconds",
+			Help:      "Time spent in a call to Publish.",
+		}, []string{"exchange", "routing_key"}),
+		HandlerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "amqp",
+			Name:      "handler_latency_seconds",
+			Help:      "End-to-end time from delivery to ack/nack.",
+		}, []string{"queue"}),
+	}
+
+	reg.MustRegister(
+		m.Published, m.Consumed, m.Acked, m.Nacked, m.Retried, m.KickbackExhausted,
+		m.Reconnects, m.InFlight, m.PublishLatency, m.HandlerLatency,
+	)
+	return m
+}
@@ -0,0 +1,42 @@
+package observability
+
+import (
+	"github.com/whiteblock/amqp"
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// instrumentedRetryStrategy decorates a queue.RetryStrategy with Retried and
+// KickbackExhausted counters and structured logging
+type instrumentedRetryStrategy struct {
+	strategy queue.RetryStrategy
+	metrics  *Metrics
+	log      logrus.Ext1FieldLogger
+}
+
+// NewInstrumentedRetryStrategy decorates strategy with metrics and
+// structured logging, without changing its retry/dead-letter behavior
+func NewInstrumentedRetryStrategy(strategy queue.RetryStrategy, metrics *Metrics, log logrus.Ext1FieldLogger) queue.RetryStrategy {
+	return &instrumentedRetryStrategy{strategy: strategy, metrics: metrics, log: log}
+}
+
+func (s *instrumentedRetryStrategy) Setup(ch externals.AMQPChannel, queueName string) error {
+	return s.strategy.Setup(ch, queueName)
+}
+
+func (s *instrumentedRetryStrategy) HandleFailure(ch externals.AMQPChannel, queueName string, d amqp.Delivery) error {
+	err := s.strategy.HandleFailure(ch, queueName, d)
+	switch err {
+	case nil:
+		s.metrics.Retried.WithLabelValues(queueName).Inc()
+		LoggerForDelivery(s.log, d).Debug("delivery handed to retry strategy")
+	case queue.ErrRetriesExhausted:
+		s.metrics.KickbackExhausted.WithLabelValues(queueName).Inc()
+		LoggerForDelivery(s.log, d).Warn("retry strategy exhausted, giving up on delivery")
+	default:
+		LoggerForDelivery(s.log, d).WithFields(logrus.Fields{"err": err}).Error("failed to handle delivery failure")
+	}
+	return err
+}
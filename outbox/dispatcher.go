@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// ReliablePublisher is the publishing dependency the Dispatcher drains the
+// outbox to. It is satisfied by queue.ReliablePublisher.
+type ReliablePublisher interface {
+	Publish(exchange, key string, msg amqp.Publishing) error
+}
+
+// DispatcherOpts configures a Dispatcher
+type DispatcherOpts struct {
+	// BatchSize is how many pending messages to fetch from the Store per poll
+	BatchSize int
+	// PollInterval is how long to wait between polls when the outbox is empty
+	PollInterval time.Duration
+	// RetryBackoff is how long to wait before retrying a batch after a publish
+	// failure
+	RetryBackoff time.Duration
+}
+
+// DefaultDispatcherOpts returns sane defaults for DispatcherOpts
+func DefaultDispatcherOpts() DispatcherOpts {
+	return DispatcherOpts{
+		BatchSize:    50,
+		PollInterval: time.Second,
+		RetryBackoff: 5 * time.Second,
+	}
+}
+
+// Dispatcher drains a Store to AMQP, retrying with backoff on publish failure
+type Dispatcher struct {
+	store Store
+	pub   ReliablePublisher
+	opts  DispatcherOpts
+	log   logrus.Ext1FieldLogger
+}
+
+// NewDispatcher creates a new Dispatcher that drains store to pub
+func NewDispatcher(log logrus.Ext1FieldLogger, store Store, pub ReliablePublisher, opts DispatcherOpts) *Dispatcher {
+	return &Dispatcher{store: store, pub: pub, opts: opts, log: log}
+}
+
+// Run polls the Store for pending messages and publishes them until ctx is
+// cancelled. It is meant to be run in its own goroutine.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		pending, sent, err := d.drainOnce()
+		switch {
+		case err != nil:
+			d.log.WithFields(logrus.Fields{"err": err}).Error("failed to drain outbox")
+			time.Sleep(d.opts.RetryBackoff)
+		case pending == 0:
+			time.Sleep(d.opts.PollInterval)
+		case sent == 0:
+			// every publish in the batch failed; back off instead of
+			// re-fetching and re-publishing the same batch in a tight loop
+			time.Sleep(d.opts.RetryBackoff)
+		}
+	}
+}
+
+// drainOnce publishes a single batch of pending messages and marks the
+// successfully published ones as sent, returning how many messages were
+// pending and how many of those were actually published
+func (d *Dispatcher) drainOnce() (pending int, sent int, err error) {
+	batch, err := d.store.PendingBatch(d.opts.BatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, msg := range batch {
+		if err := d.pub.Publish(msg.Exchange, msg.RoutingKey, msg.Publishing); err != nil {
+			d.log.WithFields(logrus.Fields{"err": err, "id": msg.ID}).Warn("failed to publish outbox message, will retry")
+			continue
+		}
+		if err := d.store.MarkSent(msg.ID); err != nil {
+			d.log.WithFields(logrus.Fields{"err": err, "id": msg.ID}).Error("published outbox message but failed to mark it sent")
+		}
+		sent++
+	}
+	return len(batch), sent, nil
+}
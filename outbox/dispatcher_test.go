@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	pending []Message
+	sent    map[string]bool
+}
+
+func (s *fakeStore) Save(tx interface{}, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, msg)
+	return nil
+}
+
+func (s *fakeStore) PendingBatch(n int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > len(s.pending) {
+		n = len(s.pending)
+	}
+	out := make([]Message, n)
+	copy(out, s.pending[:n])
+	return out, nil
+}
+
+func (s *fakeStore) MarkSent(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent == nil {
+		s.sent = map[string]bool{}
+	}
+	s.sent[id] = true
+	for i, msg := range s.pending {
+		if msg.ID == id {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type fakePublisher struct {
+	fail map[string]bool
+}
+
+func (p *fakePublisher) Publish(exchange, key string, msg amqp.Publishing) error {
+	if p.fail[exchange+key] {
+		return errors.New("publish failed")
+	}
+	return nil
+}
+
+func newTestDispatcher(store Store, pub ReliablePublisher) *Dispatcher {
+	return NewDispatcher(logrus.New(), store, pub, DefaultDispatcherOpts())
+}
+
+func TestDrainOnce_AllPublishesSucceed(t *testing.T) {
+	store := &fakeStore{pending: []Message{
+		{ID: "1", Exchange: "ex", RoutingKey: "a"},
+		{ID: "2", Exchange: "ex", RoutingKey: "b"},
+	}}
+	d := newTestDispatcher(store, &fakePublisher{})
+
+	pending, sent, err := d.drainOnce()
+	if err != nil {
+		t.Fatalf("drainOnce returned error: %v", err)
+	}
+	if pending != 2 || sent != 2 {
+		t.Fatalf("expected pending=2 sent=2, got pending=%d sent=%d", pending, sent)
+	}
+	if !store.sent["1"] || !store.sent["2"] {
+		t.Fatalf("expected both messages marked sent, got %v", store.sent)
+	}
+}
+
+func TestDrainOnce_AllPublishesFail(t *testing.T) {
+	store := &fakeStore{pending: []Message{
+		{ID: "1", Exchange: "ex", RoutingKey: "a"},
+	}}
+	d := newTestDispatcher(store, &fakePublisher{fail: map[string]bool{"exa": true}})
+
+	pending, sent, err := d.drainOnce()
+	if err != nil {
+		t.Fatalf("drainOnce returned error: %v", err)
+	}
+	if pending != 1 || sent != 0 {
+		t.Fatalf("expected pending=1 sent=0 for a fully-failed batch, got pending=%d sent=%d", pending, sent)
+	}
+	if store.sent["1"] {
+		t.Fatalf("message should not be marked sent when its publish failed")
+	}
+}
+
+func TestDrainOnce_PartialFailureLeavesFailedMessagePending(t *testing.T) {
+	store := &fakeStore{pending: []Message{
+		{ID: "1", Exchange: "ex", RoutingKey: "a"},
+		{ID: "2", Exchange: "ex", RoutingKey: "b"},
+	}}
+	d := newTestDispatcher(store, &fakePublisher{fail: map[string]bool{"exb": true}})
+
+	pending, sent, err := d.drainOnce()
+	if err != nil {
+		t.Fatalf("drainOnce returned error: %v", err)
+	}
+	if pending != 2 || sent != 1 {
+		t.Fatalf("expected pending=2 sent=1, got pending=%d sent=%d", pending, sent)
+	}
+	if !store.sent["1"] {
+		t.Fatalf("expected message 1 marked sent")
+	}
+	if store.sent["2"] {
+		t.Fatalf("message 2's publish failed, it should not be marked sent")
+	}
+}
+
+func TestDrainOnce_EmptyStore(t *testing.T) {
+	d := newTestDispatcher(&fakeStore{}, &fakePublisher{})
+
+	pending, sent, err := d.drainOnce()
+	if err != nil {
+		t.Fatalf("drainOnce returned error: %v", err)
+	}
+	if pending != 0 || sent != 0 {
+		t.Fatalf("expected pending=0 sent=0, got pending=%d sent=%d", pending, sent)
+	}
+}
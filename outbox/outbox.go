@@ -0,0 +1,39 @@
+// Package outbox implements the transactional outbox pattern for AMQP
+// publishing, letting application code write business state and outgoing
+// messages atomically in one database transaction, then have a background
+// Dispatcher drain them to the broker.
+package outbox
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Message is a message pending delivery to the broker
+type Message struct {
+	// ID uniquely identifies the outbox row, used to mark it sent
+	ID string
+	// Exchange is the exchange the message should be published to
+	Exchange string
+	// RoutingKey is the routing key the message should be published with
+	RoutingKey string
+	// Publishing is the message body and properties to publish
+	Publishing amqp.Publishing
+	// CreatedAt is when the message was written to the outbox
+	CreatedAt time.Time
+}
+
+// Store persists outgoing messages alongside application state so that a
+// single database transaction can record both atomically. tx is the
+// application's in-flight transaction (e.g. *sql.Tx); Store implementations
+// are expected to type-assert it to whatever their driver uses.
+type Store interface {
+	// Save writes msg to the outbox as part of tx
+	Save(tx interface{}, msg Message) error
+	// MarkSent marks the message identified by id as delivered
+	MarkSent(id string) error
+	// PendingBatch returns up to n messages that have not yet been sent,
+	// oldest first
+	PendingBatch(n int) ([]Message, error)
+}
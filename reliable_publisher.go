@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// ErrConfirmTimeout is returned when the broker does not ack or nack a published
+// message within the configured confirm timeout
+var ErrConfirmTimeout = errors.New("timed out waiting for publisher confirm")
+
+// ErrConfirmNack is returned when the broker nacks a published message
+var ErrConfirmNack = errors.New("broker nacked the published message")
+
+// ReliablePublisher publishes messages with publisher confirms, retrying
+// messages that time out or are nacked by the broker
+type ReliablePublisher interface {
+	// Publish publishes msg to the given exchange with the given routing key,
+	// blocking until the broker confirms the message or retries are exhausted
+	Publish(exchange, key string, msg amqp.Publishing) error
+	// Close closes the underlying channel
+	Close() error
+}
+
+// ReliablePublisherOpts configures a ReliablePublisher
+type ReliablePublisherOpts struct {
+	// ConfirmTimeout is how long to wait for a publisher confirm before
+	// treating the publish as failed and retrying
+	ConfirmTimeout time.Duration
+	// MaxRetries is the number of times to retry a publish that times out
+	// or is nacked before giving up
+	MaxRetries int
+}
+
+// DefaultReliablePublisherOpts returns sane defaults for ReliablePublisherOpts
+func DefaultReliablePublisherOpts() ReliablePublisherOpts {
+	return ReliablePublisherOpts{
+		ConfirmTimeout: 5 * time.Second,
+		MaxRetries:     3,
+	}
+}
+
+type reliablePublisher struct {
+	mu   sync.Mutex
+	ch   externals.ConfirmingAMQPChannel
+	opts ReliablePublisherOpts
+
+	confirms chan amqp.Confirmation
+	nextTag  uint64
+	pending  map[uint64]chan amqp.Confirmation
+}
+
+// NewReliablePublisher puts ch into confirm mode and returns a ReliablePublisher
+// that tracks delivery tags via a single NotifyPublish registration,
+// re-publishing messages that time out or are nacked
+func NewReliablePublisher(ch externals.AMQPChannel, opts ReliablePublisherOpts) (ReliablePublisher, error) {
+	confirmer, ok := ch.(externals.ConfirmingAMQPChannel)
+	if !ok {
+		return nil, errors.New("channel does not support publisher confirms")
+	}
+	if err := confirmer.Confirm(false); err != nil {
+		return nil, errors.Wrap(err, "failed to put channel into confirm mode")
+	}
+
+	rp := &reliablePublisher{
+		ch:       confirmer,
+		opts:     opts,
+		confirms: confirmer.NotifyPublish(make(chan amqp.Confirmation, 64)),
+		pending:  map[uint64]chan amqp.Confirmation{},
+	}
+	go rp.demux()
+	return rp, nil
+}
+
+// demux is the single reader of the channel's confirmations, routing each
+// one to the Publish call waiting on its delivery tag
+func (rp *reliablePublisher) demux() {
+	for confirm := range rp.confirms {
+		rp.mu.Lock()
+		waiter, ok := rp.pending[confirm.DeliveryTag]
+		if ok {
+			delete(rp.pending, confirm.DeliveryTag)
+		}
+		rp.mu.Unlock()
+
+		if ok {
+			waiter <- confirm
+		}
+	}
+}
+
+// Publish publishes msg, waiting for a confirm and retrying on nack or timeout
+// up to opts.MaxRetries times. Delivery tags increase by one per message
+// published on a channel in confirm mode, so rp tracks its own next tag
+// rather than relying on whatever confirmation arrives next. The tag is only
+// taken, under rp.mu, once the publish itself has succeeded, so concurrent
+// or failed publishes do not put rp's count out of step with the channel's.
+func (rp *reliablePublisher) Publish(exchange, key string, msg amqp.Publishing) error {
+	var lastErr error
+	for attempt := 0; attempt <= rp.opts.MaxRetries; attempt++ {
+		waiter := make(chan amqp.Confirmation, 1)
+
+		rp.mu.Lock()
+		tag := rp.nextTag + 1
+		if err := rp.ch.Publish(exchange, key, false, false, msg); err != nil {
+			rp.mu.Unlock()
+			lastErr = err
+			continue
+		}
+		rp.nextTag = tag
+		rp.pending[tag] = waiter
+		rp.mu.Unlock()
+
+		select {
+		case confirm := <-waiter:
+			if confirm.Ack {
+				return nil
+			}
+			lastErr = ErrConfirmNack
+		case <-time.After(rp.opts.ConfirmTimeout):
+			rp.mu.Lock()
+			delete(rp.pending, tag)
+			rp.mu.Unlock()
+			lastErr = ErrConfirmTimeout
+		}
+	}
+	return errors.Wrapf(lastErr, "publish failed after %d retries", rp.opts.MaxRetries)
+}
+
+// Close closes the underlying channel
+func (rp *reliablePublisher) Close() error {
+	return rp.ch.Close()
+}
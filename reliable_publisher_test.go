@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeConfirmingChannel mimics just enough of streadway/amqp's confirm
+// fan-out to exercise ReliablePublisher: NotifyPublish registers the single
+// channel confirmations are delivered on, and Publish assigns the next
+// delivery tag and resolves it asynchronously via decide, same as the
+// broker would from a separate goroutine. The embedded, unset
+// externals.AMQPChannel satisfies the rest of the interface; only the
+// methods ReliablePublisher actually calls are overridden below.
+type fakeConfirmingChannel struct {
+	externals.AMQPChannel
+
+	mu        sync.Mutex
+	confirmed chan amqp.Confirmation
+	nextTag   uint64
+	decide    func(tag uint64) amqp.Confirmation
+
+	notifyPublishCalls int32
+}
+
+func (f *fakeConfirmingChannel) Confirm(noWait bool) error {
+	return nil
+}
+
+func (f *fakeConfirmingChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	atomic.AddInt32(&f.notifyPublishCalls, 1)
+	f.confirmed = confirm
+	return confirm
+}
+
+func (f *fakeConfirmingChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	f.mu.Lock()
+	f.nextTag++
+	tag := f.nextTag
+	f.mu.Unlock()
+
+	go func() {
+		f.confirmed <- f.decide(tag)
+	}()
+	return nil
+}
+
+func (f *fakeConfirmingChannel) Close() error { return nil }
+
+func TestReliablePublisher_RegistersNotifyPublishOnce(t *testing.T) {
+	fc := &fakeConfirmingChannel{decide: func(tag uint64) amqp.Confirmation {
+		return amqp.Confirmation{DeliveryTag: tag, Ack: true}
+	}}
+
+	rp, err := NewReliablePublisher(fc, DefaultReliablePublisherOpts())
+	if err != nil {
+		t.Fatalf("NewReliablePublisher returned error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := rp.Publish("", "key", amqp.Publishing{}); err != nil {
+			t.Fatalf("Publish %d returned error: %v", i, err)
+		}
+	}
+
+	if calls := atomic.LoadInt32(&fc.notifyPublishCalls); calls != 1 {
+		t.Fatalf("expected NotifyPublish to be registered once, got %d calls", calls)
+	}
+}
+
+func TestReliablePublisher_RetriesOnNack(t *testing.T) {
+	var attempts int32
+	fc := &fakeConfirmingChannel{decide: func(tag uint64) amqp.Confirmation {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return amqp.Confirmation{DeliveryTag: tag, Ack: false}
+		}
+		return amqp.Confirmation{DeliveryTag: tag, Ack: true}
+	}}
+
+	rp, err := NewReliablePublisher(fc, ReliablePublisherOpts{ConfirmTimeout: time.Second, MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("NewReliablePublisher returned error: %v", err)
+	}
+
+	if err := rp.Publish("", "key", amqp.Publishing{}); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestReliablePublisher_CorrelatesOutOfOrderConfirms(t *testing.T) {
+	results := make(chan amqp.Confirmation, 2)
+	fc := &fakeConfirmingChannel{decide: func(tag uint64) amqp.Confirmation {
+		ack := tag != 1 // nack the first delivery tag, ack the second
+		c := amqp.Confirmation{DeliveryTag: tag, Ack: ack}
+		results <- c
+		return c
+	}}
+
+	rp, err := NewReliablePublisher(fc, ReliablePublisherOpts{ConfirmTimeout: time.Second, MaxRetries: 0})
+	if err != nil {
+		t.Fatalf("NewReliablePublisher returned error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() { defer wg.Done(); errs[0] = rp.Publish("", "a", amqp.Publishing{}) }()
+	go func() { defer wg.Done(); errs[1] = rp.Publish("", "b", amqp.Publishing{}) }()
+	wg.Wait()
+	<-results
+	<-results
+
+	nackCount, nilCount := 0, 0
+	for _, e := range errs {
+		if e == nil {
+			nilCount++
+		} else if e != nil {
+			nackCount++
+		}
+	}
+	if nilCount != 1 || nackCount != 1 {
+		t.Fatalf("expected exactly one publish to succeed and one to fail, got errs=%v", errs)
+	}
+}
@@ -0,0 +1,273 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/whiteblock/amqp/config"
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// Names of the built-in RetryStrategy implementations, selectable via
+// config.Consume.RetryStrategy
+const (
+	RetryStrategyCounter    = "counter"
+	RetryStrategyDeadLetter = "dead-letter"
+	RetryStrategyDelayed    = "delayed"
+)
+
+// ErrRetriesExhausted is returned by RetryStrategy.HandleFailure when it gave
+// up on a delivery instead of scheduling another attempt. The delivery has
+// already been acked, nacked, or rejected as the strategy requires; callers
+// only need this to distinguish the two outcomes for logging or metrics.
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+// RetryStrategy decides what happens to a delivery that failed processing:
+// requeue it, route it through a delay tier, or give up on it
+type RetryStrategy interface {
+	// Setup declares whatever exchanges and queues the strategy needs for
+	// queueName. Called as part of AutoSetupWithRetry.
+	Setup(ch externals.AMQPChannel, queueName string) error
+	// HandleFailure reacts to a delivery that failed processing, acking,
+	// rejecting, or re-publishing it as the strategy requires
+	HandleFailure(ch externals.AMQPChannel, queueName string, d amqp.Delivery) error
+}
+
+// NewRetryStrategy builds the RetryStrategy named by conf.RetryStrategy,
+// defaulting to the original in-band retry counter
+func NewRetryStrategy(conf config.Consume) RetryStrategy {
+	switch conf.RetryStrategy {
+	case RetryStrategyDeadLetter:
+		return &DeadLetterRetryStrategy{MaxRetries: conf.MaxRetries}
+	case RetryStrategyDelayed:
+		return &DelayedRetryStrategy{MaxRetries: conf.MaxRetries, Tiers: DefaultDelayTiers}
+	default:
+		return &CounterRetryStrategy{MaxRetries: conf.MaxRetries}
+	}
+}
+
+// AutoSetupWithRetry declares exchanges via AMQPService.CreateExchange, binds
+// queues to them, and runs strategy.Setup to declare each queue itself. The
+// queue is deliberately not also created via the plain AMQPService.CreateQueue
+// that AutoSetup uses: a RetryStrategy like DeadLetterRetryStrategy needs
+// particular queue arguments (e.g. "x-dead-letter-exchange"), and a second,
+// argument-less declare of the same queue name would conflict with those
+// rather than complement them.
+func AutoSetupWithRetry(log logrus.Ext1FieldLogger, strategy RetryStrategy, queues ...AMQPService) {
+	for i := range queues {
+		if err := queues[i].CreateExchange(); err != nil {
+			log.WithFields(logrus.Fields{"err": err}).Debug("failed to create exchange")
+		}
+	}
+
+	for i := range queues {
+		ch, err := queues[i].Channel()
+		if err != nil {
+			log.WithFields(logrus.Fields{"err": err}).Debug("failed to get channel for retry strategy setup")
+			continue
+		}
+		conf := queues[i].Config()
+		if err := strategy.Setup(ch, conf.QueueName); err != nil {
+			log.WithFields(logrus.Fields{"err": err, "queue": conf.QueueName}).Debug("failed to set up retry strategy")
+		}
+	}
+
+	BindQueuesToExchange(log, queues...)
+}
+
+// declareOriginQueue declares queueName with args, giving each RetryStrategy
+// a single place to own the origin queue's declaration instead of leaving it
+// to a separate, argument-less declare that could disagree with what the
+// strategy needs
+func declareOriginQueue(ch externals.AMQPChannel, queueName string, args amqp.Table) error {
+	_, err := ch.QueueDeclare(queueName, true, false, false, false, args)
+	return errors.Wrap(err, "failed to declare origin queue")
+}
+
+// retryCount reads the current RetryCountHeader off a delivery, treating a
+// missing header as zero prior attempts
+func retryCount(d amqp.Delivery) int64 {
+	if d.Headers == nil {
+		return 0
+	}
+	count, _ := d.Headers[RetryCountHeader].(int64)
+	return count
+}
+
+// incrementRetryHeader copies d into a Publishing with RetryCountHeader
+// incremented, the same property set GetKickbackMessage copies
+func incrementRetryHeader(d amqp.Delivery) amqp.Publishing {
+	pub := amqp.Publishing{
+		Headers:         d.Headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		Expiration:      d.Expiration,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		Body:            d.Body,
+	}
+	if pub.Headers == nil {
+		pub.Headers = map[string]interface{}{}
+	}
+	pub.Headers[RetryCountHeader] = retryCount(d) + 1
+	return pub
+}
+
+// CounterRetryStrategy is the original behavior: it mutates the
+// RetryCountHeader and republishes immediately, busy-looping the broker on
+// persistent failures and dropping the message once MaxRetries is exceeded.
+// Kept for backwards compatibility; prefer DeadLetterRetryStrategy or
+// DelayedRetryStrategy for anything consuming from a durable broker.
+type CounterRetryStrategy struct {
+	MaxRetries int64
+}
+
+// Setup declares queueName; the counter strategy needs no extra topology
+func (s *CounterRetryStrategy) Setup(ch externals.AMQPChannel, queueName string) error {
+	return declareOriginQueue(ch, queueName, nil)
+}
+
+// HandleFailure republishes d to queueName with an incremented retry count,
+// or nacks it without requeueing once MaxRetries is exceeded
+func (s *CounterRetryStrategy) HandleFailure(ch externals.AMQPChannel, queueName string, d amqp.Delivery) error {
+	pub, err := GetKickbackMessage(s.MaxRetries, d)
+	if err != nil {
+		if nackErr := d.Nack(false, false); nackErr != nil {
+			return nackErr
+		}
+		return ErrRetriesExhausted
+	}
+	if err := ch.Publish("", queueName, false, false, pub); err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+// DeadLetterRetryStrategy declares a "<queue>.dlx" exchange and a
+// "<queue>.parking" queue bound to it on Setup, and rejects a delivery with
+// requeue=false once MaxRetries is exceeded so operators can inspect
+// poisoned messages in the parking queue. Setup also owns the origin queue's
+// declaration, since it must carry the "x-dead-letter-exchange" argument
+// pointing at DeadLetterExchange for the reject to route there.
+type DeadLetterRetryStrategy struct {
+	MaxRetries int64
+}
+
+// DeadLetterExchange returns the name of the dead-letter exchange declared
+// for queueName
+func (s *DeadLetterRetryStrategy) DeadLetterExchange(queueName string) string {
+	return queueName + ".dlx"
+}
+
+// ParkingQueue returns the name of the queue poisoned messages are routed to
+func (s *DeadLetterRetryStrategy) ParkingQueue(queueName string) string {
+	return queueName + ".parking"
+}
+
+// Setup declares the dead-letter exchange and parking queue for queueName,
+// then declares the origin queue itself with its "x-dead-letter-exchange"
+// argument set so a rejected delivery actually reaches the parking queue
+func (s *DeadLetterRetryStrategy) Setup(ch externals.AMQPChannel, queueName string) error {
+	dlx := s.DeadLetterExchange(queueName)
+	parking := s.ParkingQueue(queueName)
+
+	if err := ch.ExchangeDeclare(dlx, "fanout", true, false, false, false, nil); err != nil {
+		return errors.Wrap(err, "failed to declare dead-letter exchange")
+	}
+	if _, err := ch.QueueDeclare(parking, true, false, false, false, nil); err != nil {
+		return errors.Wrap(err, "failed to declare parking queue")
+	}
+	if err := ch.QueueBind(parking, "", dlx, false, nil); err != nil {
+		return errors.Wrap(err, "failed to bind parking queue")
+	}
+
+	return declareOriginQueue(ch, queueName, amqp.Table{"x-dead-letter-exchange": dlx})
+}
+
+// HandleFailure republishes d to queueName with an incremented retry count,
+// or rejects it with requeue=false once MaxRetries is exceeded
+func (s *DeadLetterRetryStrategy) HandleFailure(ch externals.AMQPChannel, queueName string, d amqp.Delivery) error {
+	if retryCount(d) >= s.MaxRetries {
+		if err := d.Reject(false); err != nil {
+			return err
+		}
+		return ErrRetriesExhausted
+	}
+	if err := ch.Publish("", queueName, false, false, incrementRetryHeader(d)); err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+// DelayTier is one step of a delayed-retry backoff ladder: a TTL queue named
+// "<queue>.retry.<Suffix>" whose messages dead-letter back to the origin
+// queue once TTL elapses
+type DelayTier struct {
+	Suffix string
+	TTL    time.Duration
+}
+
+// DefaultDelayTiers is the 5s -> 30s -> 5m backoff ladder used when
+// DelayedRetryStrategy is built via NewRetryStrategy
+var DefaultDelayTiers = []DelayTier{
+	{Suffix: "5s", TTL: 5 * time.Second},
+	{Suffix: "30s", TTL: 30 * time.Second},
+	{Suffix: "5m", TTL: 5 * time.Minute},
+}
+
+// DelayedRetryStrategy retries a failed delivery through a ladder of
+// per-attempt TTL queues, giving exponential backoff without blocking a
+// consumer or busy-looping the broker
+type DelayedRetryStrategy struct {
+	MaxRetries int64
+	Tiers      []DelayTier
+}
+
+// RetryQueue returns the name of the TTL queue for the given tier
+func (s *DelayedRetryStrategy) RetryQueue(queueName string, tier DelayTier) string {
+	return queueName + ".retry." + tier.Suffix
+}
+
+// Setup declares the origin queue, then a TTL queue for each tier,
+// dead-lettering back to queueName
+func (s *DelayedRetryStrategy) Setup(ch externals.AMQPChannel, queueName string) error {
+	if err := declareOriginQueue(ch, queueName, nil); err != nil {
+		return err
+	}
+	for _, tier := range s.Tiers {
+		args := amqp.Table{
+			"x-message-ttl":             int64(tier.TTL / time.Millisecond),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queueName,
+		}
+		if _, err := ch.QueueDeclare(s.RetryQueue(queueName, tier), true, false, false, false, args); err != nil {
+			return errors.Wrapf(err, "failed to declare retry queue for tier %s", tier.Suffix)
+		}
+	}
+	return nil
+}
+
+// HandleFailure publishes d to the TTL queue for the next tier, or rejects
+// it with requeue=false once every tier (or MaxRetries) is exhausted
+func (s *DelayedRetryStrategy) HandleFailure(ch externals.AMQPChannel, queueName string, d amqp.Delivery) error {
+	count := retryCount(d)
+	if count >= int64(len(s.Tiers)) || count >= s.MaxRetries {
+		if err := d.Reject(false); err != nil {
+			return err
+		}
+		return ErrRetriesExhausted
+	}
+	tier := s.Tiers[count]
+	if err := ch.Publish("", s.RetryQueue(queueName, tier), false, false, incrementRetryHeader(d)); err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeAMQPChannel records Publish and QueueDeclare calls and treats
+// ExchangeDeclare and QueueBind as no-ops; any other externals.AMQPChannel
+// method panics, since the embedded interface is left unset.
+type fakeAMQPChannel struct {
+	externals.AMQPChannel
+	published    []amqp.Publishing
+	declaredArgs map[string]amqp.Table
+}
+
+func (f *fakeAMQPChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	f.published = append(f.published, msg)
+	return nil
+}
+
+func (f *fakeAMQPChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	if f.declaredArgs == nil {
+		f.declaredArgs = map[string]amqp.Table{}
+	}
+	f.declaredArgs[name] = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (f *fakeAMQPChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+func (f *fakeAMQPChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+// fakeAcknowledger records which acknowledgement method was called
+type fakeAcknowledger struct {
+	acked    bool
+	nacked   bool
+	rejected bool
+	requeue  bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error { a.acked = true; return nil }
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked, a.requeue = true, requeue
+	return nil
+}
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.rejected, a.requeue = true, requeue
+	return nil
+}
+
+func deliveryWithRetryCount(count int64) (amqp.Delivery, *fakeAcknowledger) {
+	ack := &fakeAcknowledger{}
+	d := amqp.Delivery{
+		Headers:      amqp.Table{RetryCountHeader: count},
+		Acknowledger: ack,
+	}
+	return d, ack
+}
+
+func TestCounterRetryStrategy_RepublishesUnderMaxRetries(t *testing.T) {
+	s := &CounterRetryStrategy{MaxRetries: 3}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(1)
+
+	if err := s.HandleFailure(ch, "q", d); err != nil {
+		t.Fatalf("HandleFailure returned error: %v", err)
+	}
+	if len(ch.published) != 1 {
+		t.Fatalf("expected 1 republish, got %d", len(ch.published))
+	}
+	if !ack.acked {
+		t.Fatal("expected the original delivery to be acked")
+	}
+	if got := ch.published[0].Headers[RetryCountHeader].(int64); got != 2 {
+		t.Fatalf("expected retry count 2, got %d", got)
+	}
+}
+
+func TestCounterRetryStrategy_NacksOnceRetriesExhausted(t *testing.T) {
+	s := &CounterRetryStrategy{MaxRetries: 1}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(2)
+
+	err := s.HandleFailure(ch, "q", d)
+	if err != ErrRetriesExhausted {
+		t.Fatalf("expected ErrRetriesExhausted, got %v", err)
+	}
+	if len(ch.published) != 0 {
+		t.Fatalf("expected no republish once retries are exhausted, got %d", len(ch.published))
+	}
+	if !ack.nacked || ack.requeue {
+		t.Fatalf("expected the delivery to be nacked without requeue, got nacked=%v requeue=%v", ack.nacked, ack.requeue)
+	}
+}
+
+func TestDeadLetterRetryStrategy_RepublishesUnderMaxRetries(t *testing.T) {
+	s := &DeadLetterRetryStrategy{MaxRetries: 3}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(0)
+
+	if err := s.HandleFailure(ch, "q", d); err != nil {
+		t.Fatalf("HandleFailure returned error: %v", err)
+	}
+	if len(ch.published) != 1 {
+		t.Fatalf("expected 1 republish, got %d", len(ch.published))
+	}
+	if !ack.acked {
+		t.Fatal("expected the original delivery to be acked")
+	}
+}
+
+func TestDeadLetterRetryStrategy_RejectsOnceRetriesExhausted(t *testing.T) {
+	s := &DeadLetterRetryStrategy{MaxRetries: 2}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(2)
+
+	err := s.HandleFailure(ch, "q", d)
+	if err != ErrRetriesExhausted {
+		t.Fatalf("expected ErrRetriesExhausted, got %v", err)
+	}
+	if !ack.rejected || ack.requeue {
+		t.Fatalf("expected the delivery to be rejected without requeue, got rejected=%v requeue=%v", ack.rejected, ack.requeue)
+	}
+}
+
+func TestDeadLetterRetryStrategy_SetupDeclaresOriginQueueWithDLXArg(t *testing.T) {
+	s := &DeadLetterRetryStrategy{MaxRetries: 3}
+	ch := &fakeAMQPChannel{}
+
+	if err := s.Setup(ch, "q"); err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+
+	args, ok := ch.declaredArgs["q"]
+	if !ok {
+		t.Fatal("expected the origin queue \"q\" to be declared")
+	}
+	if got := args["x-dead-letter-exchange"]; got != s.DeadLetterExchange("q") {
+		t.Fatalf("expected origin queue's x-dead-letter-exchange arg to be %q, got %q", s.DeadLetterExchange("q"), got)
+	}
+}
+
+func TestDelayedRetryStrategy_PublishesToNextTier(t *testing.T) {
+	s := &DelayedRetryStrategy{MaxRetries: 5, Tiers: DefaultDelayTiers}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(0)
+
+	if err := s.HandleFailure(ch, "q", d); err != nil {
+		t.Fatalf("HandleFailure returned error: %v", err)
+	}
+	if len(ch.published) != 1 {
+		t.Fatalf("expected 1 publish to a retry tier, got %d", len(ch.published))
+	}
+	if !ack.acked {
+		t.Fatal("expected the original delivery to be acked")
+	}
+}
+
+func TestDelayedRetryStrategy_RejectsOnceTiersExhausted(t *testing.T) {
+	s := &DelayedRetryStrategy{MaxRetries: 5, Tiers: DefaultDelayTiers}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(int64(len(DefaultDelayTiers)))
+
+	err := s.HandleFailure(ch, "q", d)
+	if err != ErrRetriesExhausted {
+		t.Fatalf("expected ErrRetriesExhausted, got %v", err)
+	}
+	if !ack.rejected || ack.requeue {
+		t.Fatalf("expected the delivery to be rejected without requeue, got rejected=%v requeue=%v", ack.rejected, ack.requeue)
+	}
+}
+
+func TestDelayedRetryStrategy_RejectsOnceMaxRetriesExceeded(t *testing.T) {
+	s := &DelayedRetryStrategy{MaxRetries: 1, Tiers: DefaultDelayTiers}
+	ch := &fakeAMQPChannel{}
+	d, ack := deliveryWithRetryCount(1)
+
+	err := s.HandleFailure(ch, "q", d)
+	if err != ErrRetriesExhausted {
+		t.Fatalf("expected ErrRetriesExhausted, got %v", err)
+	}
+	if !ack.rejected {
+		t.Fatal("expected the delivery to be rejected")
+	}
+}
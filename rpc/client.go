@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// Client is a synchronous request/reply RPC client built on top of an
+// externals.AMQPChannel. It declares a single exclusive, auto-delete reply
+// queue and multiplexes replies to waiting callers by correlation id.
+type Client struct {
+	ch      externals.AMQPChannel
+	encode  EncodeRequestFunc
+	decode  DecodeResponseFunc
+	timeout time.Duration
+
+	replyQueue string
+
+	mu      sync.Mutex
+	pending map[string]chan amqp.Delivery
+}
+
+// NewClient declares an exclusive, auto-delete reply queue on ch, starts
+// consuming from it, and returns a Client that publishes requests via ch and
+// waits up to timeout for a matching reply
+func NewClient(ch externals.AMQPChannel, encode EncodeRequestFunc, decode DecodeResponseFunc, timeout time.Duration) (*Client, error) {
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to declare reply queue")
+	}
+
+	deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume reply queue")
+	}
+
+	c := &Client{
+		ch:         ch,
+		encode:     encode,
+		decode:     decode,
+		timeout:    timeout,
+		replyQueue: q.Name,
+		pending:    map[string]chan amqp.Delivery{},
+	}
+	go c.demux(deliveries)
+	return c, nil
+}
+
+// demux dispatches each reply to the channel waiting on its correlation id
+func (c *Client) demux(deliveries <-chan amqp.Delivery) {
+	for d := range deliveries {
+		c.mu.Lock()
+		waiter, ok := c.pending[d.CorrelationId]
+		if ok {
+			delete(c.pending, d.CorrelationId)
+		}
+		c.mu.Unlock()
+
+		if ok {
+			waiter <- d
+		}
+	}
+}
+
+// Call publishes req to routingKey on the default exchange and blocks until a
+// reply with a matching correlation id arrives, ctx is cancelled, or the
+// client's timeout elapses
+func (c *Client) Call(ctx context.Context, routingKey string, req interface{}) (interface{}, error) {
+	body, err := c.encode(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode request")
+	}
+
+	corrID := uuid.New().String()
+	reply := make(chan amqp.Delivery, 1)
+
+	c.mu.Lock()
+	c.pending[corrID] = reply
+	c.mu.Unlock()
+
+	err = c.ch.Publish("", routingKey, false, false, amqp.Publishing{
+		CorrelationId: corrID,
+		ReplyTo:       c.replyQueue,
+		Body:          body,
+	})
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, corrID)
+		c.mu.Unlock()
+		return nil, errors.Wrap(err, "failed to publish request")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	select {
+	case d := <-reply:
+		return c.decode(ctx, d.Body)
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, corrID)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
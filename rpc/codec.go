@@ -0,0 +1,29 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/whiteblock/amqp"
+)
+
+// CodecDecodeFunc adapts a queue.Codec to a DecodeRequestFunc/DecodeResponseFunc,
+// decoding into a fresh value produced by newValue. The built-in codecs don't
+// need the content type out of band here since the caller already picked the
+// codec to use.
+func CodecDecodeFunc(codec queue.Codec, newValue func() interface{}) func(ctx context.Context, body []byte) (interface{}, error) {
+	return func(ctx context.Context, body []byte) (interface{}, error) {
+		v := newValue()
+		if err := codec.Decode(body, "", v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// CodecEncodeFunc adapts a queue.Codec to an EncodeRequestFunc/EncodeResponseFunc
+func CodecEncodeFunc(codec queue.Codec) func(ctx context.Context, v interface{}) ([]byte, error) {
+	return func(ctx context.Context, v interface{}) ([]byte, error) {
+		body, _, err := codec.Encode(v)
+		return body, err
+	}
+}
@@ -0,0 +1,23 @@
+// Package rpc turns an AMQPService/externals.AMQPChannel into a synchronous
+// request/reply transport, in the spirit of go-kit's AMQP transport.
+package rpc
+
+import (
+	"context"
+)
+
+// Endpoint is a single request handler, decoupled from any particular
+// transport
+type Endpoint func(ctx context.Context, request interface{}) (response interface{}, err error)
+
+// EncodeRequestFunc encodes the domain request into an amqp.Publishing body
+type EncodeRequestFunc func(ctx context.Context, request interface{}) ([]byte, error)
+
+// DecodeRequestFunc decodes an amqp.Delivery body into a domain request
+type DecodeRequestFunc func(ctx context.Context, body []byte) (interface{}, error)
+
+// EncodeResponseFunc encodes the domain response into an amqp.Publishing body
+type EncodeResponseFunc func(ctx context.Context, response interface{}) ([]byte, error)
+
+// DecodeResponseFunc decodes an amqp.Delivery body into a domain response
+type DecodeResponseFunc func(ctx context.Context, body []byte) (interface{}, error)
@@ -0,0 +1,210 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// fakeBroker is a minimal in-process stand-in for a broker's default
+// exchange: Publish delivers directly to the named queue's channel, letting
+// Client and Server be exercised together without a live connection.
+type fakeBroker struct {
+	mu      sync.Mutex
+	queues  map[string]chan amqp.Delivery
+	counter int
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{queues: map[string]chan amqp.Delivery{}}
+}
+
+func (b *fakeBroker) declareQueue(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if name == "" {
+		b.counter++
+		name = fmt.Sprintf("reply-%d", b.counter)
+	}
+	if _, ok := b.queues[name]; !ok {
+		b.queues[name] = make(chan amqp.Delivery, 16)
+	}
+	return name
+}
+
+func (b *fakeBroker) publish(key string, msg amqp.Publishing) error {
+	b.mu.Lock()
+	ch, ok := b.queues[key]
+	b.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no such queue %q", key)
+	}
+	ch <- amqp.Delivery{
+		Body:          msg.Body,
+		CorrelationId: msg.CorrelationId,
+		ReplyTo:       msg.ReplyTo,
+		Acknowledger:  nopAcknowledger{},
+	}
+	return nil
+}
+
+// nopAcknowledger satisfies amqp.Acknowledger for deliveries produced by
+// fakeBroker, since Server always acks or nacks the deliveries it handles
+type nopAcknowledger struct{}
+
+func (nopAcknowledger) Ack(tag uint64, multiple bool) error             { return nil }
+func (nopAcknowledger) Nack(tag uint64, multiple, requeue bool) error   { return nil }
+func (nopAcknowledger) Reject(tag uint64, requeue bool) error           { return nil }
+
+// fakeChannel implements externals.AMQPChannel over a shared fakeBroker
+type fakeChannel struct {
+	broker *fakeBroker
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+func (c *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.broker.mu.Lock()
+	ch := c.broker.queues[queue]
+	c.broker.mu.Unlock()
+	return ch, nil
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return c.broker.publish(key, msg)
+}
+
+func (c *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: c.broker.declareQueue(name)}, nil
+}
+
+func (c *fakeChannel) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) Tx() error         { return nil }
+func (c *fakeChannel) TxCommit() error   { return nil }
+func (c *fakeChannel) TxRollback() error { return nil }
+
+func echoEncodeRequest(ctx context.Context, request interface{}) ([]byte, error) {
+	return request.([]byte), nil
+}
+
+func echoDecodeRequest(ctx context.Context, body []byte) (interface{}, error) {
+	return body, nil
+}
+
+func echoEncodeResponse(ctx context.Context, response interface{}) ([]byte, error) {
+	return response.([]byte), nil
+}
+
+func echoDecodeResponse(ctx context.Context, body []byte) (interface{}, error) {
+	return body, nil
+}
+
+func TestClientServer_RoundTrip(t *testing.T) {
+	broker := newFakeBroker()
+	const queue = "rpc.echo"
+	broker.declareQueue(queue)
+
+	endpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.([]byte)
+		return append([]byte("echo:"), req...), nil
+	}
+
+	server := NewServer(logrus.New(), &fakeChannel{broker: broker}, endpoint, echoDecodeRequest, echoEncodeResponse)
+	if err := server.Consume(queue); err != nil {
+		t.Fatalf("Consume returned error: %v", err)
+	}
+
+	client, err := NewClient(&fakeChannel{broker: broker}, echoEncodeRequest, echoDecodeResponse, time.Second)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+
+	resp, err := client.Call(context.Background(), queue, []byte("hi"))
+	if err != nil {
+		t.Fatalf("Call returned error: %v", err)
+	}
+	if got := string(resp.([]byte)); got != "echo:hi" {
+		t.Fatalf("got response %q, want %q", got, "echo:hi")
+	}
+}
+
+func TestClientServer_ConcurrentCallsCorrelateByID(t *testing.T) {
+	broker := newFakeBroker()
+	const queue = "rpc.echo"
+	broker.declareQueue(queue)
+
+	endpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return append([]byte("echo:"), request.([]byte)...), nil
+	}
+	server := NewServer(logrus.New(), &fakeChannel{broker: broker}, endpoint, echoDecodeRequest, echoEncodeResponse)
+	if err := server.Consume(queue); err != nil {
+		t.Fatalf("Consume returned error: %v", err)
+	}
+
+	client, err := NewClient(&fakeChannel{broker: broker}, echoEncodeRequest, echoDecodeResponse, time.Second)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+
+	inputs := []string{"one", "two", "three"}
+	var wg sync.WaitGroup
+	results := make([]string, len(inputs))
+	for i, in := range inputs {
+		wg.Add(1)
+		go func(i int, in string) {
+			defer wg.Done()
+			resp, err := client.Call(context.Background(), queue, []byte(in))
+			if err != nil {
+				t.Errorf("Call(%q) returned error: %v", in, err)
+				return
+			}
+			results[i] = string(resp.([]byte))
+		}(i, in)
+	}
+	wg.Wait()
+
+	for i, in := range inputs {
+		if want := "echo:" + in; results[i] != want {
+			t.Errorf("results[%d] = %q, want %q", i, results[i], want)
+		}
+	}
+}
+
+func TestClient_TimesOutAndCleansUpPending(t *testing.T) {
+	broker := newFakeBroker()
+	const queue = "rpc.black-hole"
+	broker.declareQueue(queue) // exists, but nothing ever consumes or replies
+
+	client, err := NewClient(&fakeChannel{broker: broker}, echoEncodeRequest, echoDecodeResponse, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+
+	_, err = client.Call(context.Background(), queue, []byte("hi"))
+	if err == nil {
+		t.Fatal("expected Call to time out, got nil error")
+	}
+
+	client.mu.Lock()
+	pending := len(client.pending)
+	client.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("expected timed-out call to be removed from pending, %d still pending", pending)
+	}
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/whiteblock/amqp/externals"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// Server consumes requests from a queue, invokes an Endpoint, and publishes
+// the response to the delivery's ReplyTo with the same correlation id
+type Server struct {
+	ch       externals.AMQPChannel
+	endpoint Endpoint
+	decode   DecodeRequestFunc
+	encode   EncodeResponseFunc
+	log      logrus.Ext1FieldLogger
+}
+
+// NewServer creates a Server that serves endpoint over ch
+func NewServer(log logrus.Ext1FieldLogger, ch externals.AMQPChannel, endpoint Endpoint,
+	decode DecodeRequestFunc, encode EncodeResponseFunc) *Server {
+	return &Server{ch: ch, endpoint: endpoint, decode: decode, encode: encode, log: log}
+}
+
+// Consume starts consuming requests from queue, handling each in its own
+// goroutine
+func (s *Server) Consume(queue string) error {
+	deliveries, err := s.ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for d := range deliveries {
+			go s.handle(d)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) handle(d amqp.Delivery) {
+	ctx := context.Background()
+
+	req, err := s.decode(ctx, d.Body)
+	if err != nil {
+		s.log.WithFields(logrus.Fields{"err": err}).Error("failed to decode rpc request")
+		if nackErr := d.Nack(false, false); nackErr != nil {
+			s.log.WithFields(logrus.Fields{"err": nackErr}).Error("failed to nack rpc request")
+		}
+		return
+	}
+
+	resp, err := s.endpoint(ctx, req)
+	if err != nil {
+		s.log.WithFields(logrus.Fields{"err": err}).Error("rpc endpoint returned an error")
+		if nackErr := d.Nack(false, false); nackErr != nil {
+			s.log.WithFields(logrus.Fields{"err": nackErr}).Error("failed to nack rpc request")
+		}
+		return
+	}
+
+	if d.ReplyTo != "" {
+		body, err := s.encode(ctx, resp)
+		if err != nil {
+			s.log.WithFields(logrus.Fields{"err": err}).Error("failed to encode rpc response")
+			if ackErr := d.Ack(false); ackErr != nil {
+				s.log.WithFields(logrus.Fields{"err": ackErr}).Error("failed to ack rpc request")
+			}
+			return
+		}
+
+		err = s.ch.Publish("", d.ReplyTo, false, false, amqp.Publishing{
+			CorrelationId: d.CorrelationId,
+			Body:          body,
+		})
+		if err != nil {
+			s.log.WithFields(logrus.Fields{"err": err}).Error("failed to publish rpc response")
+		}
+	}
+
+	if err := d.Ack(false); err != nil {
+		s.log.WithFields(logrus.Fields{"err": err}).Error("failed to ack rpc request")
+	}
+}
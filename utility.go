@@ -1,7 +1,6 @@
 package queue
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/whiteblock/amqp/config"
@@ -25,22 +24,24 @@ type AMQPMessage interface {
 
 type amqpMessage struct {
 	maxRetries int64
+	codec      Codec
 }
 
-// NewAMQPMessage creates a new AMQPMessage
-func NewAMQPMessage(maxRetries int64) AMQPMessage {
-	return &amqpMessage{maxRetries: maxRetries}
+// NewAMQPMessage creates a new AMQPMessage. The codec used to encode message
+// bodies is selected from pub.ContentType, defaulting to JSON.
+func NewAMQPMessage(maxRetries int64, pub config.Publish) AMQPMessage {
+	return &amqpMessage{maxRetries: maxRetries, codec: CodecForContentType(pub.ContentType)}
 }
 
 // CreateMessage creates a message from the given body
 func (am amqpMessage) CreateMessage(body interface{}) (amqp.Publishing, error) {
-	return CreateMessage(body)
+	return CreateMessage(am.codec, body)
 }
 
 // GetNextMessage is similar to GetKickbackMessage but takes in a new body, and does not increment the
 // retry count
 func (am amqpMessage) GetNextMessage(msg amqp.Delivery, body interface{}) (amqp.Publishing, error) {
-	return GetNextMessage(msg, body)
+	return GetNextMessage(am.codec, msg, body)
 }
 
 // GetKickbackMessage takes the delivery and creates a message from it
@@ -131,9 +132,10 @@ func BindQueuesToExchange(log logrus.Ext1FieldLogger, queues ...AMQPService) {
 	}
 }
 
-// CreateMessage creates a message from the given body
-func CreateMessage(body interface{}) (amqp.Publishing, error) {
-	rawBody, err := json.Marshal(body)
+// CreateMessage creates a message from the given body, encoding it with codec
+// and setting ContentType to match
+func CreateMessage(codec Codec, body interface{}) (amqp.Publishing, error) {
+	rawBody, contentType, err := codec.Encode(body)
 	if err != nil {
 		return amqp.Publishing{}, err
 	}
@@ -142,22 +144,23 @@ func CreateMessage(body interface{}) (amqp.Publishing, error) {
 		Headers: map[string]interface{}{
 			RetryCountHeader: int64(0),
 		},
-		Body: rawBody,
+		ContentType: contentType,
+		Body:        rawBody,
 	}
 	return pub, nil
 }
 
 // GetNextMessage is similar to GetKickbackMessage but takes in a new body, and does not increment the
 // retry count
-func GetNextMessage(msg amqp.Delivery, body interface{}) (amqp.Publishing, error) {
-	rawBody, err := json.Marshal(body)
+func GetNextMessage(codec Codec, msg amqp.Delivery, body interface{}) (amqp.Publishing, error) {
+	rawBody, contentType, err := codec.Encode(body)
 	if err != nil {
 		return amqp.Publishing{}, err
 	}
 	pub := amqp.Publishing{
 		Headers: msg.Headers,
 		// Properties
-		ContentType:     msg.ContentType,
+		ContentType:     contentType,
 		ContentEncoding: msg.ContentEncoding,
 		DeliveryMode:    msg.DeliveryMode,
 		Type:            msg.Type,